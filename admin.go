@@ -0,0 +1,29 @@
+// Operator-facing endpoints for inspecting and canceling in-flight downloads.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// adminDownloadsHandler serves GET /_admin/downloads (list) and
+// DELETE /_admin/downloads/{id} (cancel).
+func adminDownloadsHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/_admin/downloads"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(fileCache.Downloads())
+	case r.Method == http.MethodDelete && id != "":
+		if !fileCache.CancelDownload(id) {
+			http.Error(w, "no such download", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestServer serves content as name, relying on http.ServeContent to handle
+// both Range requests (what ChunkedParallel's probe needs) and conditional
+// If-Modified-Since requests (what revalidation needs) the same way a real
+// pacman mirror would.
+func newTestServer(name string, content []byte, modTime time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, name, modTime, bytes.NewReader(content))
+	}))
+}
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	c, err := New(ctx, t.TempDir(), VerifyOff)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+// TestGetCacheMiss covers the most basic path through Cache.Get: nothing on disk
+// yet, so it must fetch from url and let the caller read the bytes back out as
+// they land, without ever seeing "file does not exist" from newLiveReader.
+func TestGetCacheMiss(t *testing.T) {
+	content := []byte("this is definitely a pacman repo database")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	srv := newTestServer("core.db", content, modTime)
+	defer srv.Close()
+
+	c := newTestCache(t)
+
+	reader, err := c.Get("core.db", srv.URL, false)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer reader.Close()
+
+	got := make([]byte, len(content))
+	if _, err := io.ReadFull(reader, got); err != nil {
+		t.Fatalf("reading served content: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("got %q, want %q", got, content)
+	}
+}
+
+// TestGetRevalidateNotModified covers revalidating an already-cached file (the
+// path mirror.go takes on every request for a .db) against a server that
+// correctly answers 304. The existing cached copy must still be served, not
+// wiped out and turned into an error.
+func TestGetRevalidateNotModified(t *testing.T) {
+	content := []byte("this is definitely a pacman repo database")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	srv := newTestServer("core.db", content, modTime)
+	defer srv.Close()
+
+	c := newTestCache(t)
+
+	filePath := c.path("core.db")
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatalf("seeding cache file: %v", err)
+	}
+	if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+		t.Fatalf("seeding cache file mtime: %v", err)
+	}
+
+	reader, err := c.Get("core.db", srv.URL, true)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer reader.Close()
+
+	got := make([]byte, len(content))
+	if _, err := io.ReadFull(reader, got); err != nil {
+		t.Fatalf("reading served content: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("got %q, want %q", got, content)
+	}
+
+	onDisk, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("cached file was removed: %v", err)
+	}
+	if !bytes.Equal(onDisk, content) {
+		t.Fatalf("cached file on disk changed: got %q, want %q", onDisk, content)
+	}
+}
+
+// TestGetChunkedMultiChunk forces ChunkedParallel down its real multi-chunk path
+// (rather than the single-probe-chunk path every other test in this file takes) by
+// shrinking ChunkSize/MinChunkSize, with a file size that leaves a runt trailing
+// chunk right after chunk 0 - the case buildChunks must not merge into chunk 0,
+// since chunk 0's bytes are fixed by the probe request and can't be extended after
+// the fact.
+func TestGetChunkedMultiChunk(t *testing.T) {
+	origChunkSize, origMinChunkSize := ChunkSize, MinChunkSize
+	ChunkSize, MinChunkSize = 1024, 200
+	t.Cleanup(func() { ChunkSize, MinChunkSize = origChunkSize, origMinChunkSize })
+
+	content := make([]byte, 1124) // one full ChunkSize chunk plus a 100-byte runt
+	for i := range content {
+		content[i] = byte(i)
+	}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	srv := newTestServer("core.db", content, modTime)
+	defer srv.Close()
+
+	c := newTestCache(t)
+
+	reader, err := c.Get("core.db", srv.URL, false)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer reader.Close()
+
+	got := make([]byte, len(content))
+	if _, err := io.ReadFull(reader, got); err != nil {
+		t.Fatalf("reading served content: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		for i := range content {
+			if i >= len(got) || got[i] != content[i] {
+				t.Fatalf("content corrupted starting at byte %d (got %d bytes, want %d)", i, len(got), len(content))
+			}
+		}
+	}
+}
+
+func TestCachePath(t *testing.T) {
+	c := newTestCache(t)
+	want := filepath.Join(c.dir, "core.db")
+	if got := c.path("core.db"); got != want {
+		t.Fatalf("path(%q) = %q, want %q", "core.db", got, want)
+	}
+}
@@ -0,0 +1,46 @@
+// DiskReader wraps a cache entry that has already finished downloading.
+
+package cache
+
+import "os"
+
+type DiskReader struct {
+	file  *os.File
+	entry Entry
+}
+
+func newDiskReader(filePath string) (*DiskReader, error) {
+	file, err := os.OpenFile(filePath, os.O_RDONLY, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := readEntry(filePath)
+	if err != nil {
+		// no sidecar yet (e.g. the file predates this cache) - fall back to the file's own stat
+		stat, statErr := file.Stat()
+		if statErr != nil {
+			file.Close()
+			return nil, statErr
+		}
+		entry = Entry{Size: stat.Size(), ModTime: stat.ModTime()}
+	}
+
+	return &DiskReader{file: file, entry: entry}, nil
+}
+
+func (r *DiskReader) Read(p []byte) (int, error) {
+	return r.file.Read(p)
+}
+
+func (r *DiskReader) Seek(offset int64, whence int) (int64, error) {
+	return r.file.Seek(offset, whence)
+}
+
+func (r *DiskReader) Close() error {
+	return r.file.Close()
+}
+
+func (r *DiskReader) GetEntry() Entry {
+	return r.entry
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Jabbermuggel/go-mirror-server/ranges"
+)
+
+// activeDownload tracks a single in-progress download: how large it will end up
+// being, which byte ranges have actually landed on disk so far (not necessarily
+// contiguous, once secondary range fetchers get involved), and a condition variable
+// LiveReaders can wait on instead of polling the file on disk.
+type activeDownload struct {
+	id        string
+	name      string
+	url       string
+	startedAt time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	size         int64
+	sizeKnown    bool
+	written      ranges.Ranges
+	lastModified time.Time
+	finished     bool
+	err          error
+
+	// fetches tracks secondary range fetchers spawned for readers that seek ahead of
+	// the primary download, keyed by chunk index (byte offset / ChunkSize).
+	fetches map[int64]*rangeFetch
+	// readers maps each attached LiveReader to its last known read position, so a
+	// reader that falls too far behind a faster sibling can be dropped.
+	readers map[*LiveReader]int64
+
+	mu   *sync.Mutex
+	cond *sync.Cond
+}
+
+// markWritten records [start, end) as landed on disk and wakes anyone waiting on it.
+func (dl *activeDownload) markWritten(start, end int64) {
+	dl.mu.Lock()
+	dl.written.Add(start, end)
+	dl.cond.Broadcast()
+	dl.mu.Unlock()
+}
+
+// markSize records the download's total size once the strategy's probe/HTTP
+// response reports it, and wakes anyone waiting in waitForSizeKnown - a LiveReader
+// handed out before this point would report Size 0, which http.ServeContent treats
+// as "nothing to send".
+func (dl *activeDownload) markSize(size int64) {
+	dl.mu.Lock()
+	dl.size = size
+	dl.sizeKnown = true
+	dl.cond.Broadcast()
+	dl.mu.Unlock()
+}
+
+// waitForSizeKnown blocks until dl.size is known or the download finishes (or fails)
+// without ever learning it, whichever comes first.
+func (dl *activeDownload) waitForSizeKnown() error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	for !dl.sizeKnown && !dl.finished {
+		dl.cond.Wait()
+	}
+	if !dl.sizeKnown {
+		if dl.err != nil {
+			return dl.err
+		}
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// download registers a new activeDownload for name (or returns the one already in
+// flight) and kicks off the fetch in the background.
+func (c *Cache) download(name, filePath, url string, ifModifiedSince time.Time) (*activeDownload, error) {
+	dl, tracked := c.downloader.Track(name, url)
+	if tracked {
+		return dl, nil
+	}
+
+	// Create the destination file synchronously, before handing back dl: callers open
+	// filePath for reading right away via newLiveReader, and the strategy itself only
+	// creates it after its own HTTP round-trip (the probe request, for ChunkedParallel),
+	// which would otherwise lose the race against that open. Crucially this must not
+	// truncate an already-cached file: on revalidate, the existing content is what a
+	// LiveReader serves if the strategy comes back with ErrNotModified.
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		c.downloader.untrack(name)
+		return nil, err
+	}
+	file.Close()
+
+	go c.runDownload(filePath, dl, ifModifiedSince)
+	return dl, nil
+}
+
+func (c *Cache) runDownload(filePath string, dl *activeDownload, ifModifiedSince time.Time) {
+	err := c.strategy.Download(dl.ctx, dl.url, filePath, ifModifiedSince, dl)
+
+	if errors.Is(err, ErrNotModified) {
+		c.finishNotModified(filePath, dl, ifModifiedSince)
+		return
+	}
+
+	var sha256sum string
+	if err == nil {
+		sha256sum, err = c.verify(dl.name, filePath)
+	}
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Printf("download of %v canceled", dl.url)
+		} else {
+			log.Printf("download of %v failed: %v", dl.url, err)
+		}
+		_ = os.Remove(filePath)
+	} else {
+		if !dl.lastModified.IsZero() {
+			_ = os.Chtimes(filePath, time.Now(), dl.lastModified)
+		}
+
+		_ = writeEntry(filePath, Entry{URL: dl.url, Size: dl.size, ModTime: dl.lastModified, SHA256: sha256sum})
+
+		log.Printf("Download of file %v done!", dl.name)
+	}
+
+	c.downloader.untrack(dl.name)
+
+	dl.mu.Lock()
+	dl.finished = true
+	dl.err = err
+	dl.cond.Broadcast()
+	dl.mu.Unlock()
+}
+
+// finishNotModified handles a strategy reporting ErrNotModified: the file on disk
+// (left untouched by download, since it's only ever created, never truncated, ahead
+// of a revalidate) is still good, so LiveReaders piggybacking on this download just
+// get pointed at the whole thing instead of at an error.
+func (c *Cache) finishNotModified(filePath string, dl *activeDownload, ifModifiedSince time.Time) {
+	log.Printf("%v not modified since %v, keeping cached copy", dl.url, ifModifiedSince)
+
+	stat, statErr := os.Stat(filePath)
+
+	c.downloader.untrack(dl.name)
+
+	dl.mu.Lock()
+	if statErr == nil {
+		dl.size = stat.Size()
+	}
+	dl.sizeKnown = true
+	dl.lastModified = ifModifiedSince
+	dl.finished = true
+	dl.written.Add(0, dl.size)
+	dl.cond.Broadcast()
+	dl.mu.Unlock()
+}
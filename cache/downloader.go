@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Downloader tracks every download currently in flight. It hands out IDs so an
+// operator can look a download up or cancel it later, and makes sure at most one
+// download is ever running for a given file name.
+type Downloader struct {
+	ctx context.Context
+
+	mu        sync.Mutex
+	downloads map[string]*activeDownload // by id
+	byName    map[string]string          // file name -> id
+}
+
+func newDownloader(ctx context.Context) *Downloader {
+	return &Downloader{
+		ctx:       ctx,
+		downloads: make(map[string]*activeDownload),
+		byName:    make(map[string]string),
+	}
+}
+
+// Track registers name/url under a fresh UUID, deriving a cancelable context from the
+// Downloader's root context, and returns the new activeDownload. If name is already
+// being downloaded, the existing activeDownload is returned instead and tracked is true.
+func (d *Downloader) Track(name, url string) (dl *activeDownload, tracked bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if id, ok := d.byName[name]; ok {
+		return d.downloads[id], true
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	dl = &activeDownload{
+		id:        uuid.NewString(),
+		name:      name,
+		url:       url,
+		ctx:       ctx,
+		cancel:    cancel,
+		startedAt: time.Now(),
+		mu:        &sync.Mutex{},
+	}
+	dl.cond = sync.NewCond(dl.mu)
+
+	d.downloads[dl.id] = dl
+	d.byName[name] = dl.id
+	return dl, false
+}
+
+// Get looks a download up by its UUID.
+func (d *Downloader) Get(id string) (*activeDownload, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dl, ok := d.downloads[id]
+	return dl, ok
+}
+
+// ByName looks a download up by the file name it's fetching.
+func (d *Downloader) ByName(name string) (*activeDownload, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id, ok := d.byName[name]
+	if !ok {
+		return nil, false
+	}
+	return d.downloads[id], true
+}
+
+// Cancel stops the download with the given id, if one is running. It reports whether a
+// download was found; the download itself unwinds asynchronously once its strategy
+// notices ctx is done.
+func (d *Downloader) Cancel(id string) bool {
+	d.mu.Lock()
+	dl, ok := d.downloads[id]
+	d.mu.Unlock()
+	if !ok {
+		return false
+	}
+	dl.cancel()
+	return true
+}
+
+// All returns a snapshot of every download currently tracked.
+func (d *Downloader) All() []*activeDownload {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	all := make([]*activeDownload, 0, len(d.downloads))
+	for _, dl := range d.downloads {
+		all = append(all, dl)
+	}
+	return all
+}
+
+func (d *Downloader) untrack(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if id, ok := d.byName[name]; ok {
+		delete(d.downloads, id)
+		delete(d.byName, name)
+	}
+}
+
+// DownloadStatus is a point-in-time snapshot of an in-progress download, safe to
+// marshal and hand back from an admin API.
+type DownloadStatus struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Size      int64     `json:"size"`
+	Progress  int64     `json:"progress"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+func (dl *activeDownload) status() DownloadStatus {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return DownloadStatus{
+		ID:        dl.id,
+		Name:      dl.name,
+		URL:       dl.url,
+		Size:      dl.size,
+		Progress:  dl.written.ContiguousFromZero(),
+		StartedAt: dl.startedAt,
+	}
+}
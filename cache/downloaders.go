@@ -0,0 +1,160 @@
+// This file generalizes the original single-producer piggyback mechanism: a
+// download can now have several readers at different offsets, and a reader that
+// seeks far ahead of what's on disk gets its own secondary ranged fetch instead of
+// just blocking behind the primary download.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// rangeFetchIdleTimeout is how long a secondary fetch will keep running after the
+// last reader that needed it stopped asking for more.
+const rangeFetchIdleTimeout = 5 * time.Second
+
+// maxReaderLag is how far behind the fastest reader on a download another reader
+// may fall before it gets closed instead of held open.
+const maxReaderLag = 1024 * 1024
+
+// rangeFetch is a secondary download filling in one chunk-sized gap on behalf of a
+// reader that jumped ahead of the primary download.
+type rangeFetch struct {
+	mu        sync.Mutex
+	lastTouch time.Time
+}
+
+func (rf *rangeFetch) touch() {
+	rf.mu.Lock()
+	rf.lastTouch = time.Now()
+	rf.mu.Unlock()
+}
+
+func (rf *rangeFetch) idleFor() time.Duration {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return time.Since(rf.lastTouch)
+}
+
+// ensureCoverage makes sure the chunk containing byte offset `from` is either already
+// on disk or being fetched, spawning a secondary range fetch otherwise. It's called
+// from LiveReader.waitForSize when a reader needs bytes well beyond what the primary
+// download has reached yet.
+func (dl *activeDownload) ensureCoverage(filePath string, from int64) {
+	key := from / ChunkSize
+
+	dl.mu.Lock()
+	if dl.fetches == nil {
+		dl.fetches = make(map[int64]*rangeFetch)
+	}
+	rf, exists := dl.fetches[key]
+	size := dl.size
+	dl.mu.Unlock()
+
+	if exists {
+		rf.touch()
+		return
+	}
+	if size == 0 {
+		return
+	}
+
+	start := key * ChunkSize
+	end := start + ChunkSize
+	if end > size {
+		end = size
+	}
+
+	rf = &rangeFetch{lastTouch: time.Now()}
+	dl.mu.Lock()
+	dl.fetches[key] = rf
+	dl.mu.Unlock()
+
+	go dl.runRangeFetch(rf, filePath, key, start, end)
+}
+
+func (dl *activeDownload) runRangeFetch(rf *rangeFetch, filePath string, key, start, end int64) {
+	defer func() {
+		dl.mu.Lock()
+		delete(dl.fetches, key)
+		dl.mu.Unlock()
+	}()
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("range fetch %v[%d:%d]: %v", dl.url, start, end, err)
+		return
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(dl.ctx)
+	defer cancel()
+
+	idleCheck := time.NewTicker(time.Second)
+	defer idleCheck.Stop()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-idleCheck.C:
+				if rf.idleFor() > rangeFetchIdleTimeout {
+					log.Printf("range fetch %v[%d:%d] idle, stopping", dl.url, start, end)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	client := &http.Client{}
+	if err := downloadChunk(ctx, client, dl.url, file, chunkRange{start: start, end: end - 1}); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("range fetch %v[%d:%d] failed: %v", dl.url, start, end, err)
+		}
+		return
+	}
+	dl.markWritten(start, end)
+}
+
+// registerReader and unregisterReader let the download keep track of which readers
+// are currently attached, so it can drop ones that fall too far behind a sibling.
+func (dl *activeDownload) registerReader(r *LiveReader) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.readers == nil {
+		dl.readers = make(map[*LiveReader]int64)
+	}
+	dl.readers[r] = 0
+}
+
+func (dl *activeDownload) unregisterReader(r *LiveReader) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	delete(dl.readers, r)
+}
+
+// checkLag records r's current position and reports whether it has fallen more than
+// maxReaderLag behind the fastest reader attached to the same download.
+func (dl *activeDownload) checkLag(r *LiveReader, position int64) bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.readers == nil {
+		return false
+	}
+	dl.readers[r] = position
+
+	var fastest int64
+	for _, pos := range dl.readers {
+		if pos > fastest {
+			fastest = pos
+		}
+	}
+	return fastest-position > maxReaderLag
+}
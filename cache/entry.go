@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Entry is the metadata we keep about a cached file, whether it is still
+// downloading or has finished and settled on disk. It is persisted next to the
+// file itself (<name>.meta) once a download has finished and passed verification,
+// so a restart doesn't lose a cached file's size or the URL it came from;
+// DiskReader reads it back. A download cut short by a crash leaves no .meta
+// behind, and LiveReader never reads one.
+type Entry struct {
+	URL     string    `json:"url"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	ETag    string    `json:"etag,omitempty"`
+	SHA256  string    `json:"sha256,omitempty"`
+}
+
+func metaPath(filePath string) string {
+	return filePath + ".meta"
+}
+
+func readEntry(filePath string) (Entry, error) {
+	var entry Entry
+	data, err := os.ReadFile(metaPath(filePath))
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(data, &entry)
+	return entry, err
+}
+
+func writeEntry(filePath string, entry Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath(filePath), data, 0644)
+}
@@ -0,0 +1,12 @@
+package cache
+
+import "io"
+
+// Reader is what Cache.Get hands back to a caller: something that behaves like
+// an open file for a cached entry, whether that entry has finished downloading
+// (DiskReader) or is still being written to (LiveReader).
+type Reader interface {
+	io.ReadCloser
+	io.Seeker
+	GetEntry() Entry
+}
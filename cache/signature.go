@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// verifyPGPSignature checks the detached signature at sigPath against filePath, using
+// the keys in the keyring at keyringPath.
+func verifyPGPSignature(keyringPath, filePath, sigPath string) error {
+	keyringFile, err := os.Open(keyringPath)
+	if err != nil {
+		return err
+	}
+	defer keyringFile.Close()
+
+	keyring, err := openpgp.ReadKeyRing(keyringFile)
+	if err != nil {
+		return err
+	}
+
+	signed, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer signed.Close()
+
+	sig, err := os.Open(sigPath)
+	if err != nil {
+		return err
+	}
+	defer sig.Close()
+
+	_, err = openpgp.CheckDetachedSignature(keyring, signed, sig)
+	return err
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DownloadStrategy knows how to fetch url into filePath, reporting progress onto dl
+// (size, progress, lastModified, guarded by dl.mu) as bytes land so any LiveReaders
+// piggybacking on the download wake up as soon as there's something new to read.
+// Implementations should honor ctx and return ctx.Err() once it's done. If
+// ifModifiedSince is set and the server reports the file unchanged (HTTP 304), the
+// implementation must return ErrNotModified rather than treating it as a failure.
+type DownloadStrategy interface {
+	Download(ctx context.Context, url, filePath string, ifModifiedSince time.Time, dl *activeDownload) error
+}
+
+// ErrNotModified is returned by a DownloadStrategy when the upstream server answers
+// a conditional request with 304 Not Modified: the cached copy on disk is still
+// good, so this isn't treated as a failed download.
+var ErrNotModified = errors.New("not modified")
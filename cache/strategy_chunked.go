@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Tunables for ChunkedParallel. Defaults are conservative enough that a handful of
+// concurrent cache misses for the same file don't fan out into hundreds of upstream
+// connections - the primary download of a file uses at most MaxConcurrency of them,
+// and there's only ever one activeDownload per file since everyone else piggybacks
+// on it. Secondary range fetches for readers that seek ahead (see downloaders.go)
+// come on top of that cap.
+var (
+	ChunkSize      int64 = 8 * 1024 * 1024
+	MaxConcurrency       = 4
+	MinChunkSize   int64 = 1 * 1024 * 1024
+)
+
+// ChunkedParallel fetches a file as several disjoint byte ranges in parallel
+// (inspired by pget's buffer mode), falling back to SingleStream when the upstream
+// server doesn't cooperate - no ranged requests, or an unknown Content-Length.
+type ChunkedParallel struct{}
+
+type chunkRange struct {
+	index      int
+	start, end int64 // inclusive
+}
+
+func (ChunkedParallel) Download(ctx context.Context, url, filePath string, ifModifiedSince time.Time, dl *activeDownload) error {
+	client := &http.Client{}
+
+	probe, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if !ifModifiedSince.IsZero() {
+		probe.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	probe.Header.Set("Accept-Encoding", "identity")
+	probe.Header.Set("Range", fmt.Sprintf("bytes=0-%d", ChunkSize-1))
+
+	resp, err := client.Do(probe)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return ErrNotModified
+	}
+
+	total := resp.ContentLength
+	if resp.StatusCode == http.StatusPartialContent {
+		if _, size, ok := parseContentRange(resp.Header.Get("Content-Range")); ok {
+			total = size
+		}
+	}
+	if resp.StatusCode != http.StatusPartialContent || total <= 0 {
+		log.Printf("server does not support ranged downloads for %v, falling back to single stream", url)
+		return SingleStream{}.Download(ctx, url, filePath, ifModifiedSince, dl)
+	}
+
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		if parsed, err := http.ParseTime(lastModified); err == nil {
+			dl.mu.Lock()
+			dl.lastModified = parsed
+			dl.mu.Unlock()
+		}
+	}
+	dl.markSize(total)
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Truncate(total); err != nil {
+		return err
+	}
+
+	chunks := buildChunks(total)
+	if _, err := io.Copy(io.NewOffsetWriter(file, 0), resp.Body); err != nil {
+		return err
+	}
+	dl.markWritten(chunks[0].start, chunks[0].end+1)
+
+	log.Printf("downloading %v in %d chunks (%d bytes)", url, len(chunks), total)
+
+	return downloadRemainingChunks(ctx, client, url, file, chunks, dl)
+}
+
+// buildChunks splits [0, total) into roughly ChunkSize-sized ranges, merging a
+// trailing runt chunk into its predecessor so the last one is never smaller than
+// MinChunkSize - unless the predecessor is chunk 0, which is never merged into.
+// Chunk 0's bytes are already on their way in via the initial probe request, sized
+// to a fixed ChunkSize; extending its range after the fact would claim bytes past
+// what that request actually returned, which downloadRemainingChunks then never goes
+// back and fetches.
+func buildChunks(total int64) []chunkRange {
+	var chunks []chunkRange
+	index := 0
+	for start := int64(0); start < total; {
+		end := start + ChunkSize - 1
+		if end > total-1 {
+			end = total - 1
+		}
+		chunks = append(chunks, chunkRange{index: index, start: start, end: end})
+		index++
+		start = end + 1
+	}
+	if len(chunks) > 2 {
+		last := chunks[len(chunks)-1]
+		if last.end-last.start+1 < MinChunkSize {
+			chunks = chunks[:len(chunks)-1]
+			chunks[len(chunks)-1].end = last.end
+		}
+	}
+	return chunks
+}
+
+// downloadRemainingChunks fetches chunks[1:] (chunk 0 was already pulled in by the
+// initial probe request) with up to MaxConcurrency workers. Chunks can land out of
+// order - dl.markWritten/dl.written (a ranges.Ranges bitmap) is what lets readers
+// figure out what's actually available regardless of the order chunks finish in.
+func downloadRemainingChunks(ctx context.Context, client *http.Client, url string, file *os.File, chunks []chunkRange, dl *activeDownload) error {
+	if len(chunks) == 1 {
+		return nil
+	}
+
+	jobs := make(chan chunkRange)
+	errs := make(chan error, len(chunks))
+
+	concurrency := MaxConcurrency
+	if concurrency > len(chunks)-1 {
+		concurrency = len(chunks) - 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				if err := downloadChunk(ctx, client, url, file, c); err != nil {
+					errs <- err
+					continue
+				}
+				dl.markWritten(c.start, c.end+1)
+			}
+		}()
+	}
+
+	for _, c := range chunks[1:] {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadChunk(ctx context.Context, client *http.Client, url string, file *os.File, c chunkRange) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", c.start, c.end))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("chunk %d: expected 206 Partial Content, got %d", c.index, resp.StatusCode)
+	}
+
+	_, err = io.Copy(io.NewOffsetWriter(file, c.start), resp.Body)
+	return err
+}
+
+// parseContentRange parses a "bytes start-end/size" Content-Range header.
+func parseContentRange(header string) (end int64, size int64, ok bool) {
+	var start int64
+	n, err := fmt.Sscanf(header, "bytes %d-%d/%d", &start, &end, &size)
+	if err != nil || n != 3 {
+		return 0, 0, false
+	}
+	return end, size, true
+}
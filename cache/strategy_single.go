@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cavaliergopher/grab/v3"
+)
+
+// SingleStream downloads the whole file over one HTTP connection using grab, same as
+// the mirror has always done. ChunkedParallel falls back to this when the upstream
+// server doesn't support ranged requests.
+type SingleStream struct{}
+
+func (SingleStream) Download(ctx context.Context, url, filePath string, ifModifiedSince time.Time, dl *activeDownload) error {
+	client := grab.NewClient()
+	req, err := grab.NewRequest(filePath, url)
+	if err != nil {
+		return err
+	}
+	req.NoResume = true
+	req.HTTPRequest = req.HTTPRequest.WithContext(ctx)
+	if !ifModifiedSince.IsZero() {
+		req.HTTPRequest.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	// golang requests compression for all requests except HEAD. some servers return compressed data without Content-Length header info. disable compression as it useless for package data
+	req.HTTPRequest.Header.Add("Accept-Encoding", "identity")
+	resp := client.Do(req)
+
+	if resp.HTTPResponse == nil {
+		// the request failed before any response arrived, e.g. because ctx was canceled
+		return resp.Err()
+	}
+	if resp.HTTPResponse.StatusCode == http.StatusNotModified {
+		return ErrNotModified
+	}
+
+	if lastModified := resp.HTTPResponse.Header.Get("Last-Modified"); lastModified != "" {
+		if parsed, err := http.ParseTime(lastModified); err == nil {
+			dl.mu.Lock()
+			dl.lastModified = parsed
+			dl.mu.Unlock()
+		}
+	}
+	dl.markSize(resp.Size())
+
+	log.Printf("downloading %v (single stream)", url)
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+Loop:
+	for {
+		select {
+		case <-ticker.C:
+			dl.markWritten(0, resp.BytesComplete())
+		case <-resp.Done:
+			break Loop
+		}
+	}
+
+	dl.markWritten(0, resp.BytesComplete())
+
+	// resp.Err() surfaces ctx.Err() too, since canceling ctx aborts the underlying
+	// http.Request and grab reports that as the transfer's error.
+	return resp.Err()
+}
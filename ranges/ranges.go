@@ -0,0 +1,56 @@
+// Package ranges implements a small non-overlapping interval set, used to track which
+// byte ranges of a file being downloaded have actually landed on disk.
+package ranges
+
+import "sort"
+
+// span is a half-open [Start, End) byte range.
+type span struct {
+	Start, End int64
+}
+
+// Ranges tracks the byte ranges of a file that have already been written, merging
+// adjacent and overlapping spans as they're added. The zero value is an empty set.
+type Ranges struct {
+	spans []span
+}
+
+// Add records [start, end) as covered.
+func (r *Ranges) Add(start, end int64) {
+	if end <= start {
+		return
+	}
+	r.spans = append(r.spans, span{start, end})
+	sort.Slice(r.spans, func(i, j int) bool { return r.spans[i].Start < r.spans[j].Start })
+
+	merged := r.spans[:0]
+	for _, s := range r.spans {
+		if len(merged) > 0 && s.Start <= merged[len(merged)-1].End {
+			if s.End > merged[len(merged)-1].End {
+				merged[len(merged)-1].End = s.End
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	r.spans = merged
+}
+
+// Covers reports whether [start, end) is entirely covered by a single recorded span.
+func (r *Ranges) Covers(start, end int64) bool {
+	for _, s := range r.spans {
+		if s.Start <= start && end <= s.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ContiguousFromZero returns how many bytes from the start of the file are
+// contiguously covered - handy for reporting overall download progress.
+func (r *Ranges) ContiguousFromZero() int64 {
+	if len(r.spans) == 0 || r.spans[0].Start != 0 {
+		return 0
+	}
+	return r.spans[0].End
+}
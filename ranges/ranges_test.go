@@ -0,0 +1,105 @@
+package ranges
+
+import "testing"
+
+func TestRangesCoversEmpty(t *testing.T) {
+	var r Ranges
+	if r.Covers(0, 1) {
+		t.Fatal("empty Ranges must not cover anything")
+	}
+}
+
+func TestRangesAddAndCovers(t *testing.T) {
+	var r Ranges
+	r.Add(0, 10)
+
+	if !r.Covers(0, 10) {
+		t.Fatal("expected [0,10) to be covered")
+	}
+	if !r.Covers(2, 5) {
+		t.Fatal("expected a sub-range to be covered")
+	}
+	if r.Covers(0, 11) {
+		t.Fatal("must not cover past what was added")
+	}
+	if r.Covers(5, 15) {
+		t.Fatal("must not cover a range that only partially overlaps")
+	}
+}
+
+func TestRangesAddMergesAdjacent(t *testing.T) {
+	var r Ranges
+	r.Add(0, 10)
+	r.Add(10, 20)
+
+	if !r.Covers(0, 20) {
+		t.Fatal("adjacent spans should merge into one covering the whole range")
+	}
+}
+
+func TestRangesAddMergesOverlapping(t *testing.T) {
+	var r Ranges
+	r.Add(0, 10)
+	r.Add(5, 15)
+
+	if !r.Covers(0, 15) {
+		t.Fatal("overlapping spans should merge")
+	}
+}
+
+func TestRangesAddOutOfOrder(t *testing.T) {
+	var r Ranges
+	r.Add(20, 30)
+	r.Add(0, 10)
+	r.Add(10, 20)
+
+	if !r.Covers(0, 30) {
+		t.Fatal("out-of-order adds that together cover a range should merge into one span")
+	}
+}
+
+func TestRangesAddLeavesGap(t *testing.T) {
+	var r Ranges
+	r.Add(0, 10)
+	r.Add(20, 30)
+
+	if r.Covers(0, 30) {
+		t.Fatal("a gap between spans must not be reported as covered")
+	}
+	if !r.Covers(0, 10) || !r.Covers(20, 30) {
+		t.Fatal("each span on its own should still be covered")
+	}
+}
+
+func TestRangesAddEmptyIsNoop(t *testing.T) {
+	var r Ranges
+	r.Add(5, 5)
+	r.Add(10, 5)
+
+	if r.Covers(0, 100) {
+		t.Fatal("zero-length and inverted Adds must not record any coverage")
+	}
+}
+
+func TestRangesContiguousFromZero(t *testing.T) {
+	var r Ranges
+
+	if got := r.ContiguousFromZero(); got != 0 {
+		t.Fatalf("empty Ranges: ContiguousFromZero() = %d, want 0", got)
+	}
+
+	r.Add(10, 20)
+	if got := r.ContiguousFromZero(); got != 0 {
+		t.Fatalf("span not starting at 0 must not count: got %d, want 0", got)
+	}
+
+	r.Add(0, 10)
+	if got := r.ContiguousFromZero(); got != 20 {
+		t.Fatalf("after filling the gap: ContiguousFromZero() = %d, want 20", got)
+	}
+
+	r.Add(25, 30)
+	if got := r.ContiguousFromZero(); got != 20 {
+		t.Fatalf("a later disjoint span must not extend the contiguous count: got %d, want 20", got)
+	}
+}
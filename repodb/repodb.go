@@ -0,0 +1,83 @@
+// Package repodb reads just enough of a pacman repo database (the gzipped tar
+// that ships as reponame.db) to answer "what's the sha256sum of this package
+// file", which is all the mirror needs it for.
+package repodb
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Package is the subset of a repo database's per-package "desc" entry we care about.
+type Package struct {
+	FileName string
+	SHA256   string
+}
+
+// SHA256For opens the repo database at dbPath and returns the sha256sum recorded
+// for fileName in its "desc" entry.
+func SHA256For(dbPath, fileName string) (string, error) {
+	f, err := os.Open(dbPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasSuffix(hdr.Name, "/desc") {
+			continue
+		}
+
+		pkg, err := parseDesc(tr)
+		if err != nil {
+			return "", err
+		}
+		if pkg.FileName == fileName {
+			return pkg.SHA256, nil
+		}
+	}
+	return "", fmt.Errorf("package %s not found in %s", fileName, dbPath)
+}
+
+// parseDesc reads a single "desc" file's %KEY%\nvalue\n\n blocks and pulls out the
+// two fields we need.
+func parseDesc(r io.Reader) (Package, error) {
+	var pkg Package
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return pkg, err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		switch strings.TrimSpace(lines[i]) {
+		case "%FILENAME%":
+			if i+1 < len(lines) {
+				pkg.FileName = strings.TrimSpace(lines[i+1])
+			}
+		case "%SHA256SUM%":
+			if i+1 < len(lines) {
+				pkg.SHA256 = strings.TrimSpace(lines[i+1])
+			}
+		}
+	}
+	return pkg, nil
+}